@@ -0,0 +1,165 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "io"
+    "log"
+    "math/rand"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "golang.org/x/time/rate"
+)
+
+// apiClient is a shared, connection-reusing HTTP client for the Joplin Web
+// Clipper API. A single instance is meant to be reused across every
+// makeAPIRequest call so that concurrent workers share both the underlying
+// connection pool and the rate limiter.
+type apiClient struct {
+    httpClient *http.Client
+    limiter    *rate.Limiter
+    apiBase    string
+    token      string
+    maxRetries int
+}
+
+// newAPIClient builds an apiClient tuned for cfg.Concurrency in-flight
+// requests. The limiter caps request throughput so a high --concurrency
+// doesn't overwhelm Joplin's local SQLite-backed API.
+func newAPIClient(cfg Config) *apiClient {
+    concurrency := cfg.Concurrency
+    if concurrency < 1 {
+        concurrency = 1
+    }
+
+    maxRetries := cfg.MaxRetries
+    if maxRetries < 1 {
+        maxRetries = 1
+    }
+
+    return &apiClient{
+        httpClient: &http.Client{
+            Timeout: cfg.Timeout,
+            Transport: &http.Transport{
+                MaxIdleConnsPerHost: concurrency,
+            },
+        },
+        limiter:    rate.NewLimiter(rate.Limit(concurrency*4), concurrency),
+        apiBase:    cfg.APIBase,
+        token:      cfg.Token,
+        maxRetries: maxRetries,
+    }
+}
+
+// bufferToReadCloser wraps bytes.Buffer to reuse the request body.
+func bufferToReadCloser(buf *bytes.Buffer) io.ReadCloser {
+    if buf == nil {
+        return io.NopCloser(bytes.NewBuffer(nil))
+    }
+    // Create a copy of the buffer for a new request to avoid the "body already read" error
+    return io.NopCloser(bytes.NewBuffer(buf.Bytes()))
+}
+
+// backoffWithJitter returns the delay to wait before retry attempt i,
+// growing exponentially and randomized so that many concurrent workers
+// retrying at once don't all hammer the API on the same tick.
+func backoffWithJitter(attempt int) time.Duration {
+    base := time.Second * time.Duration(int64(1)<<uint(attempt))
+    return base/2 + time.Duration(rand.Int63n(int64(base/2)+1))
+}
+
+// isRetryableStatus reports whether status warrants a backoff-and-retry
+// rather than an immediate failure.
+func isRetryableStatus(status int) bool {
+    return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// sleepWithContext waits out d, or returns ctx.Err() as soon as ctx is
+// cancelled, so a SIGINT/SIGTERM during a multi-second backoff aborts
+// immediately instead of waiting for the full delay.
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+    select {
+    case <-time.After(d):
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// makeAPIRequest makes an HTTP request with authentication and retry logic.
+// The request is bound to ctx so an in-flight attempt is aborted as soon as
+// the caller cancels (e.g. on SIGINT/SIGTERM). Connection errors, 429s and
+// 5xxs are retried with exponential backoff and jitter; other errors are
+// returned immediately.
+func (c *apiClient) makeAPIRequest(ctx context.Context, method, endpoint string, body *bytes.Buffer) ([]byte, error) {
+    u, err := url.Parse(c.apiBase + endpoint)
+    if err != nil {
+        return nil, fmt.Errorf("URL parsing error: %w", err)
+    }
+
+    q := u.Query()
+    q.Set("token", c.token)
+    u.RawQuery = q.Encode()
+    fullURL := u.String()
+
+    for i := 0; i < c.maxRetries; i++ {
+        if err := c.limiter.Wait(ctx); err != nil {
+            return nil, err
+        }
+
+        var requestBody io.Reader
+        if body != nil {
+            requestBody = bufferToReadCloser(body)
+        }
+
+        req, err := http.NewRequestWithContext(ctx, method, fullURL, requestBody)
+        if err != nil {
+            return nil, fmt.Errorf("request creation error: %w", err)
+        }
+        if body != nil {
+            req.Header.Set("Content-Type", "application/json")
+        }
+
+        resp, err := c.httpClient.Do(req)
+        if err != nil {
+            if ctx.Err() != nil {
+                return nil, ctx.Err()
+            }
+            log.Printf("Error executing request to %s (trying %d): %v", fullURL, i+1, err)
+            if err := sleepWithContext(ctx, backoffWithJitter(i)); err != nil {
+                return nil, err
+            }
+            continue
+        }
+
+        respBody, _ := io.ReadAll(resp.Body)
+        resp.Body.Close()
+
+        if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+            if isRetryableStatus(resp.StatusCode) {
+                log.Printf("Retryable status %d from %s (trying %d)", resp.StatusCode, fullURL, i+1)
+                if err := sleepWithContext(ctx, backoffWithJitter(i)); err != nil {
+                    return nil, err
+                }
+                continue
+            }
+
+            // Because Joplin returns 500 if the tag exists, but this is not a critical error for us.
+            // We just don't update the map, and move on to the next tag.
+            errorString := string(respBody)
+            if strings.Contains(errorString, "already exists") {
+                return nil, fmt.Errorf("tag already exists: %s", errorString)
+            }
+
+            return nil, fmt.Errorf("API error. Status: %s (%d). Respond: %s", resp.Status, resp.StatusCode, respBody)
+        }
+
+        return respBody, nil
+    }
+
+    return nil, fmt.Errorf("request failed after %d attempts", c.maxRetries)
+}
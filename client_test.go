@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+// TestNewAPIClientClampsMaxRetries guards against a silent all-requests-fail
+// bug: --max-retries 0 (or JOPLIN_MAX_RETRIES=0) must not leave maxRetries
+// at 0, since makeAPIRequest's `for i := 0; i < c.maxRetries; i++` loop
+// would then never attempt a single request.
+func TestNewAPIClientClampsMaxRetries(t *testing.T) {
+    cfg := defaultConfig()
+    cfg.MaxRetries = 0
+
+    client := newAPIClient(cfg)
+
+    if client.maxRetries < 1 {
+        t.Fatalf("maxRetries = %d, want at least 1", client.maxRetries)
+    }
+}
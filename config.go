@@ -0,0 +1,195 @@
+package main
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "regexp"
+    "strconv"
+    "time"
+
+    "gopkg.in/yaml.v3"
+)
+
+// Config holds every tunable that used to live in hardcoded constants. It is
+// assembled in precedence order: flags (applied by the caller) > environment
+// variables > the optional YAML config file > the defaults below.
+type Config struct {
+    APIBase     string            `yaml:"api_base"`
+    Token       string            `yaml:"token"`
+    TagPrefix   string            `yaml:"tag_prefix"`
+    MaxRetries  int               `yaml:"max_retries"`
+    Timeout     time.Duration     `yaml:"timeout"`
+    Concurrency int               `yaml:"concurrency"`
+    Include     string            `yaml:"include"` // regex matched against notebook titles
+    Exclude     string            `yaml:"exclude"` // regex matched against notebook titles
+    Overrides   map[string]string `yaml:"overrides"` // folder ID -> tag name override
+}
+
+func defaultConfig() Config {
+    return Config{
+        APIBase:     "http://localhost:41184",
+        TagPrefix:   "notebook.",
+        MaxRetries:  3,
+        Timeout:     10 * time.Second,
+        Concurrency: 8,
+    }
+}
+
+// configFilePath returns $XDG_CONFIG_HOME/joplin-autotagger/config.yaml,
+// falling back to $HOME/.config when XDG_CONFIG_HOME is unset.
+func configFilePath() (string, error) {
+    dir := os.Getenv("XDG_CONFIG_HOME")
+    if dir == "" {
+        home, err := os.UserHomeDir()
+        if err != nil {
+            return "", fmt.Errorf("resolving home directory: %w", err)
+        }
+        dir = filepath.Join(home, ".config")
+    }
+    return filepath.Join(dir, "joplin-autotagger", "config.yaml"), nil
+}
+
+// loadConfigFile reads and parses the YAML config file, returning a zero
+// Config (not an error) when no file is present.
+func loadConfigFile() (Config, error) {
+    var cfg Config
+
+    path, err := configFilePath()
+    if err != nil {
+        return cfg, err
+    }
+
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return cfg, nil
+    }
+    if err != nil {
+        return cfg, fmt.Errorf("reading config file %s: %w", path, err)
+    }
+
+    if err := yaml.Unmarshal(data, &cfg); err != nil {
+        return cfg, fmt.Errorf("parsing config file %s: %w", path, err)
+    }
+    return cfg, nil
+}
+
+// mergeConfig overlays every non-zero field of override onto base.
+func mergeConfig(base *Config, override Config) {
+    if override.APIBase != "" {
+        base.APIBase = override.APIBase
+    }
+    if override.Token != "" {
+        base.Token = override.Token
+    }
+    if override.TagPrefix != "" {
+        base.TagPrefix = override.TagPrefix
+    }
+    if override.MaxRetries != 0 {
+        base.MaxRetries = override.MaxRetries
+    }
+    if override.Timeout != 0 {
+        base.Timeout = override.Timeout
+    }
+    if override.Concurrency != 0 {
+        base.Concurrency = override.Concurrency
+    }
+    if override.Include != "" {
+        base.Include = override.Include
+    }
+    if override.Exclude != "" {
+        base.Exclude = override.Exclude
+    }
+    if override.Overrides != nil {
+        base.Overrides = override.Overrides
+    }
+}
+
+// applyEnv overlays the JOPLIN_* environment variables onto cfg.
+func applyEnv(cfg *Config) {
+    if v := os.Getenv("JOPLIN_API_BASE"); v != "" {
+        cfg.APIBase = v
+    }
+    if v := os.Getenv("JOPLIN_TOKEN"); v != "" {
+        cfg.Token = v
+    }
+    if v := os.Getenv("JOPLIN_TAG_PREFIX"); v != "" {
+        cfg.TagPrefix = v
+    }
+    if v := os.Getenv("JOPLIN_MAX_RETRIES"); v != "" {
+        if n, err := strconv.Atoi(v); err == nil {
+            cfg.MaxRetries = n
+        }
+    }
+    if v := os.Getenv("JOPLIN_TIMEOUT"); v != "" {
+        if d, err := time.ParseDuration(v); err == nil {
+            cfg.Timeout = d
+        }
+    }
+    if v := os.Getenv("JOPLIN_CONCURRENCY"); v != "" {
+        if n, err := strconv.Atoi(v); err == nil {
+            cfg.Concurrency = n
+        }
+    }
+    if v := os.Getenv("JOPLIN_INCLUDE"); v != "" {
+        cfg.Include = v
+    }
+    if v := os.Getenv("JOPLIN_EXCLUDE"); v != "" {
+        cfg.Exclude = v
+    }
+}
+
+// loadConfig assembles defaults, the config file, and the environment.
+// Flag overrides are applied by the caller, since only it knows which flags
+// the user actually passed on the command line.
+func loadConfig() (Config, error) {
+    cfg := defaultConfig()
+
+    fileCfg, err := loadConfigFile()
+    if err != nil {
+        return cfg, err
+    }
+    mergeConfig(&cfg, fileCfg)
+    applyEnv(&cfg)
+    return cfg, nil
+}
+
+// titleFilter compiles Include/Exclude into a predicate over notebook
+// titles: a title passes if Include is empty or matches it, and Exclude is
+// empty or does not match it.
+func (c Config) titleFilter() (func(title string) bool, error) {
+    var includeRe, excludeRe *regexp.Regexp
+    var err error
+
+    if c.Include != "" {
+        includeRe, err = regexp.Compile(c.Include)
+        if err != nil {
+            return nil, fmt.Errorf("invalid --include pattern %q: %w", c.Include, err)
+        }
+    }
+    if c.Exclude != "" {
+        excludeRe, err = regexp.Compile(c.Exclude)
+        if err != nil {
+            return nil, fmt.Errorf("invalid --exclude pattern %q: %w", c.Exclude, err)
+        }
+    }
+
+    return func(title string) bool {
+        if includeRe != nil && !includeRe.MatchString(title) {
+            return false
+        }
+        if excludeRe != nil && excludeRe.MatchString(title) {
+            return false
+        }
+        return true
+    }, nil
+}
+
+// tagNameForFolder applies an --overrides entry for folderID if one exists,
+// otherwise falls back to the hierarchical tag name already computed for it.
+func (c Config) tagNameForFolder(folderID, computedTagName string) string {
+    if override, ok := c.Overrides[folderID]; ok {
+        return override
+    }
+    return computedTagName
+}
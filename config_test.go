@@ -0,0 +1,141 @@
+package main
+
+import (
+    "os"
+    "reflect"
+    "testing"
+    "time"
+)
+
+func TestMergeConfig(t *testing.T) {
+    base := defaultConfig()
+    override := Config{
+        APIBase:     "http://example.com",
+        TagPrefix:   "tag.",
+        MaxRetries:  5,
+        Timeout:     30 * time.Second,
+        Concurrency: 16,
+        Include:     "Work.*",
+        Overrides:   map[string]string{"folder-id": "custom"},
+    }
+
+    mergeConfig(&base, override)
+
+    if base.APIBase != override.APIBase {
+        t.Errorf("APIBase = %q, want %q", base.APIBase, override.APIBase)
+    }
+    if base.TagPrefix != override.TagPrefix {
+        t.Errorf("TagPrefix = %q, want %q", base.TagPrefix, override.TagPrefix)
+    }
+    if base.MaxRetries != override.MaxRetries {
+        t.Errorf("MaxRetries = %d, want %d", base.MaxRetries, override.MaxRetries)
+    }
+    if base.Timeout != override.Timeout {
+        t.Errorf("Timeout = %v, want %v", base.Timeout, override.Timeout)
+    }
+    if base.Concurrency != override.Concurrency {
+        t.Errorf("Concurrency = %d, want %d", base.Concurrency, override.Concurrency)
+    }
+    if base.Include != override.Include {
+        t.Errorf("Include = %q, want %q", base.Include, override.Include)
+    }
+    if base.Exclude != "" {
+        t.Errorf("Exclude = %q, want empty (override left it zero)", base.Exclude)
+    }
+    if base.Overrides["folder-id"] != "custom" {
+        t.Errorf("Overrides[folder-id] = %q, want %q", base.Overrides["folder-id"], "custom")
+    }
+}
+
+func TestMergeConfigZeroOverrideLeavesBaseUntouched(t *testing.T) {
+    base := defaultConfig()
+    mergeConfig(&base, Config{})
+
+    if !reflect.DeepEqual(base, defaultConfig()) {
+        t.Errorf("zero-value override changed base config: got %+v", base)
+    }
+}
+
+func TestApplyEnv(t *testing.T) {
+    for _, key := range []string{
+        "JOPLIN_API_BASE", "JOPLIN_TOKEN", "JOPLIN_TAG_PREFIX", "JOPLIN_MAX_RETRIES",
+        "JOPLIN_TIMEOUT", "JOPLIN_CONCURRENCY", "JOPLIN_INCLUDE", "JOPLIN_EXCLUDE",
+    } {
+        if _, set := os.LookupEnv(key); set {
+            t.Setenv(key, "")
+            os.Unsetenv(key)
+        }
+    }
+
+    t.Setenv("JOPLIN_API_BASE", "http://env.example.com")
+    t.Setenv("JOPLIN_TOKEN", "env-token")
+    t.Setenv("JOPLIN_MAX_RETRIES", "7")
+    t.Setenv("JOPLIN_TIMEOUT", "5s")
+    t.Setenv("JOPLIN_CONCURRENCY", "not-a-number")
+
+    cfg := defaultConfig()
+    applyEnv(&cfg)
+
+    if cfg.APIBase != "http://env.example.com" {
+        t.Errorf("APIBase = %q, want env value", cfg.APIBase)
+    }
+    if cfg.Token != "env-token" {
+        t.Errorf("Token = %q, want env value", cfg.Token)
+    }
+    if cfg.MaxRetries != 7 {
+        t.Errorf("MaxRetries = %d, want 7", cfg.MaxRetries)
+    }
+    if cfg.Timeout != 5*time.Second {
+        t.Errorf("Timeout = %v, want 5s", cfg.Timeout)
+    }
+    // An unparsable value must be ignored, leaving the default in place.
+    if cfg.Concurrency != defaultConfig().Concurrency {
+        t.Errorf("Concurrency = %d, want default %d preserved on parse error", cfg.Concurrency, defaultConfig().Concurrency)
+    }
+}
+
+func TestTitleFilter(t *testing.T) {
+    tests := []struct {
+        name    string
+        cfg     Config
+        title   string
+        want    bool
+        wantErr bool
+    }{
+        {name: "no filters passes everything", cfg: Config{}, title: "Anything", want: true},
+        {name: "include matches", cfg: Config{Include: "^Work"}, title: "Work/Projects", want: true},
+        {name: "include does not match", cfg: Config{Include: "^Work"}, title: "Personal", want: false},
+        {name: "exclude matches", cfg: Config{Exclude: "Archive"}, title: "2024 Archive", want: false},
+        {name: "exclude does not match", cfg: Config{Exclude: "Archive"}, title: "Active", want: true},
+        {name: "invalid include regex errors", cfg: Config{Include: "("}, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            filter, err := tt.cfg.titleFilter()
+            if tt.wantErr {
+                if err == nil {
+                    t.Fatal("expected an error, got nil")
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("unexpected error: %v", err)
+            }
+            if got := filter(tt.title); got != tt.want {
+                t.Errorf("filter(%q) = %v, want %v", tt.title, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestTagNameForFolder(t *testing.T) {
+    cfg := Config{Overrides: map[string]string{"folder-1": "custom.name"}}
+
+    if got := cfg.tagNameForFolder("folder-1", "notebook.Computed"); got != "custom.name" {
+        t.Errorf("got %q, want override %q", got, "custom.name")
+    }
+    if got := cfg.tagNameForFolder("folder-2", "notebook.Computed"); got != "notebook.Computed" {
+        t.Errorf("got %q, want computed fallback %q", got, "notebook.Computed")
+    }
+}
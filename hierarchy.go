@@ -0,0 +1,119 @@
+package main
+
+import "strings"
+
+// HierarchyOptions controls how nested notebooks are translated into tag
+// names.
+type HierarchyOptions struct {
+    Sep            string
+    MaxDepth       int
+    LeafOnly       bool
+    ApplyAncestors bool
+}
+
+// buildFolderTagNames walks the notebook tree (folders linked by ParentID)
+// and returns, for every folder ID, the prefixed tag name synthesized from
+// its ancestry (e.g. "notebook.Work/Projects/Alpha"), plus the chain of
+// folder IDs from that folder up to the root, leaf-first. The chain is what
+// --apply-ancestors uses to tag a note with every ancestor notebook's tag.
+func buildFolderTagNames(folders []Folder, tagPrefix string, hopts HierarchyOptions) (tagNames map[string]string, ancestorChain map[string][]string) {
+    byID := make(map[string]Folder, len(folders))
+    for _, f := range folders {
+        byID[f.ID] = f
+    }
+
+    sep := hopts.Sep
+    if sep == "" {
+        sep = "/"
+    }
+
+    // visiting guards both closures against a cyclic ParentID chain (e.g. a
+    // corrupted or imported database where a notebook is its own indirect
+    // parent), which would otherwise recurse until the stack overflows.
+    // Re-entering an id still being resolved means a cycle was found, so
+    // that branch of the walk is simply cut short there.
+    visiting := make(map[string]bool, len(folders))
+
+    titlePathMemo := make(map[string][]string)
+    var titlePath func(id string) []string
+    titlePath = func(id string) []string {
+        if p, ok := titlePathMemo[id]; ok {
+            return p
+        }
+        f, ok := byID[id]
+        if !ok {
+            return nil
+        }
+        if visiting[id] {
+            return nil
+        }
+        visiting[id] = true
+        defer delete(visiting, id)
+
+        var parent []string
+        if f.ParentID != "" {
+            parent = titlePath(f.ParentID)
+        }
+        full := make([]string, 0, len(parent)+1)
+        full = append(full, parent...)
+        full = append(full, f.Title)
+        titlePathMemo[id] = full
+        return full
+    }
+
+    idChainMemo := make(map[string][]string)
+    var idChain func(id string) []string
+    idChain = func(id string) []string {
+        if c, ok := idChainMemo[id]; ok {
+            return c
+        }
+        f, ok := byID[id]
+        if !ok {
+            return nil
+        }
+        if visiting[id] {
+            return nil
+        }
+        visiting[id] = true
+        defer delete(visiting, id)
+
+        chain := []string{id}
+        if f.ParentID != "" {
+            chain = append(chain, idChain(f.ParentID)...)
+        }
+        idChainMemo[id] = chain
+        return chain
+    }
+
+    tagNames = make(map[string]string, len(folders))
+    ancestorChain = make(map[string][]string, len(folders))
+    for _, f := range folders {
+        full := titlePath(f.ID)
+
+        path := full
+        switch {
+        case hopts.LeafOnly:
+            path = full[len(full)-1:]
+        case hopts.MaxDepth > 0 && len(full) > hopts.MaxDepth:
+            path = full[len(full)-hopts.MaxDepth:]
+        }
+
+        tagNames[f.ID] = tagPrefix + strings.Join(path, sep)
+        ancestorChain[f.ID] = idChain(f.ID)
+    }
+    return tagNames, ancestorChain
+}
+
+// tagFolderIDsForNote returns the folder IDs whose tag should be applied to
+// a note parented at parentFolderID: just that folder, or its whole
+// ancestor chain when ApplyAncestors is set.
+func tagFolderIDsForNote(parentFolderID string, ancestorChain map[string][]string, applyAncestors bool) []string {
+    chain, ok := ancestorChain[parentFolderID]
+    if !ok {
+        return nil
+    }
+    if !applyAncestors {
+        return chain[:1]
+    }
+    return chain
+}
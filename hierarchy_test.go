@@ -0,0 +1,137 @@
+package main
+
+import (
+    "reflect"
+    "testing"
+    "time"
+)
+
+func TestBuildFolderTagNames(t *testing.T) {
+    folders := []Folder{
+        {ID: "root", Title: "Work", ParentID: ""},
+        {ID: "child", Title: "Projects", ParentID: "root"},
+        {ID: "grandchild", Title: "Alpha", ParentID: "child"},
+    }
+
+    tests := []struct {
+        name          string
+        hopts         HierarchyOptions
+        wantTagNames  map[string]string
+        wantAncestors map[string][]string
+    }{
+        {
+            name:  "full hierarchy with default separator",
+            hopts: HierarchyOptions{},
+            wantTagNames: map[string]string{
+                "root":       "notebook.Work",
+                "child":      "notebook.Work/Projects",
+                "grandchild": "notebook.Work/Projects/Alpha",
+            },
+            wantAncestors: map[string][]string{
+                "root":       {"root"},
+                "child":      {"child", "root"},
+                "grandchild": {"grandchild", "child", "root"},
+            },
+        },
+        {
+            name:  "custom separator",
+            hopts: HierarchyOptions{Sep: "::"},
+            wantTagNames: map[string]string{
+                "root":       "notebook.Work",
+                "child":      "notebook.Work::Projects",
+                "grandchild": "notebook.Work::Projects::Alpha",
+            },
+        },
+        {
+            name:  "leaf only ignores ancestry in the tag name",
+            hopts: HierarchyOptions{LeafOnly: true},
+            wantTagNames: map[string]string{
+                "root":       "notebook.Work",
+                "child":      "notebook.Projects",
+                "grandchild": "notebook.Alpha",
+            },
+        },
+        {
+            name:  "max depth caps the number of ancestor levels",
+            hopts: HierarchyOptions{MaxDepth: 2},
+            wantTagNames: map[string]string{
+                "root":       "notebook.Work",
+                "child":      "notebook.Work/Projects",
+                "grandchild": "notebook.Projects/Alpha",
+            },
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tagNames, ancestorChain := buildFolderTagNames(folders, "notebook.", tt.hopts)
+
+            for id, want := range tt.wantTagNames {
+                if got := tagNames[id]; got != want {
+                    t.Errorf("tagNames[%q] = %q, want %q", id, got, want)
+                }
+            }
+            for id, want := range tt.wantAncestors {
+                if got := ancestorChain[id]; !reflect.DeepEqual(got, want) {
+                    t.Errorf("ancestorChain[%q] = %v, want %v", id, got, want)
+                }
+            }
+        })
+    }
+}
+
+// TestBuildFolderTagNamesCyclicParents guards against a corrupted or
+// imported notebook tree where ParentID chains back on itself; it must
+// terminate instead of recursing forever.
+func TestBuildFolderTagNamesCyclicParents(t *testing.T) {
+    folders := []Folder{
+        {ID: "a", Title: "A", ParentID: "b"},
+        {ID: "b", Title: "B", ParentID: "a"},
+    }
+
+    done := make(chan struct{})
+    var tagNames map[string]string
+    var ancestorChain map[string][]string
+    go func() {
+        tagNames, ancestorChain = buildFolderTagNames(folders, "notebook.", HierarchyOptions{})
+        close(done)
+    }()
+
+    select {
+    case <-done:
+    case <-time.After(2 * time.Second):
+        t.Fatal("buildFolderTagNames did not terminate on a cyclic ParentID chain")
+    }
+
+    if len(tagNames) != 2 || len(ancestorChain) != 2 {
+        t.Fatalf("expected a tag name and ancestor chain for both folders despite the cycle, got tagNames=%v ancestorChain=%v", tagNames, ancestorChain)
+    }
+}
+
+func TestTagFolderIDsForNote(t *testing.T) {
+    ancestorChain := map[string][]string{
+        "grandchild": {"grandchild", "child", "root"},
+    }
+
+    t.Run("direct notebook only", func(t *testing.T) {
+        got := tagFolderIDsForNote("grandchild", ancestorChain, false)
+        want := []string{"grandchild"}
+        if !reflect.DeepEqual(got, want) {
+            t.Errorf("got %v, want %v", got, want)
+        }
+    })
+
+    t.Run("apply ancestors returns the full chain", func(t *testing.T) {
+        got := tagFolderIDsForNote("grandchild", ancestorChain, true)
+        want := []string{"grandchild", "child", "root"}
+        if !reflect.DeepEqual(got, want) {
+            t.Errorf("got %v, want %v", got, want)
+        }
+    })
+
+    t.Run("unknown folder returns nil", func(t *testing.T) {
+        if got := tagFolderIDsForNote("missing", ancestorChain, false); got != nil {
+            t.Errorf("got %v, want nil", got)
+        }
+    })
+}
@@ -0,0 +1,32 @@
+package main
+
+import "encoding/json"
+
+// === DATA STRUCTURES FOR API ===
+
+// Structure for the notation file (Folder in API)
+type Folder struct {
+    ID       string `json:"id"`
+    Title    string `json:"title"`
+    ParentID string `json:"parent_id"` // ID of the parent notebook, empty at the root
+}
+
+// Structure for the tag
+type Tag struct {
+    ID    string `json:"id"`
+    Title string `json:"title"`
+}
+
+// Structure for the note
+type Note struct {
+    ID       string `json:"id"`
+    Title    string `json:"title"`
+    ParentID string `json:"parent_id"` // ID of parent notebook (folder)
+}
+
+// General structure for paginated result
+type PaginatedResponse struct {
+    Items      json.RawMessage `json:"items"`
+    HasMore    bool            `json:"has_more"`
+    TotalItems int             `json:"total_items"`
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "strings"
+)
+
+// streamAll walks a paginated endpoint page by page, pushing each decoded
+// item onto the returned channel as soon as its page arrives. The next page
+// is fetched in the background goroutine while the current one is still
+// being drained by the caller, so memory stays bounded to one page instead
+// of the whole collection. The error channel carries at most one error and
+// is closed once the item channel is closed; a caller should drain items
+// first, then check it. ctx cancellation stops the fetch loop early.
+func streamAll[T any](ctx context.Context, client *apiClient, endpoint string) (<-chan T, <-chan error) {
+    itemCh := make(chan T, 100)
+    errCh := make(chan error, 1)
+
+    baseEndpoint := endpoint
+    if !strings.Contains(baseEndpoint, "?") {
+        baseEndpoint += "?"
+    } else {
+        baseEndpoint += "&"
+    }
+
+    go func() {
+        defer close(itemCh)
+        defer close(errCh)
+
+        page := 1
+        limit := 100
+
+        for {
+            // Since `endpoint` already contains initial fields, we only add pagination
+            pagedEndpoint := fmt.Sprintf("%s%d&page=%d", baseEndpoint, limit, page)
+
+            respBody, err := client.makeAPIRequest(ctx, "GET", pagedEndpoint, nil)
+            if err != nil {
+                errCh <- err
+                return
+            }
+
+            var pagedResponse PaginatedResponse
+            if err := json.Unmarshal(respBody, &pagedResponse); err != nil {
+                errCh <- fmt.Errorf("paginated response parsing error: %w", err)
+                return
+            }
+
+            var items []T
+            if err := json.Unmarshal(pagedResponse.Items, &items); err != nil {
+                errCh <- fmt.Errorf("element parsing error: %w", err)
+                return
+            }
+
+            for _, item := range items {
+                select {
+                case itemCh <- item:
+                case <-ctx.Done():
+                    return
+                }
+            }
+
+            if !pagedResponse.HasMore {
+                return
+            }
+            page++
+        }
+    }()
+
+    return itemCh, errCh
+}
+
+// fetchAll is a thin wrapper over streamAll for callers that genuinely need
+// the complete collection up front (e.g. building the folder parent-ID
+// index, where ancestry can't be resolved until every folder is known).
+func fetchAll[T any](ctx context.Context, client *apiClient, endpoint string) ([]T, error) {
+    itemCh, errCh := streamAll[T](ctx, client, endpoint)
+
+    var allItems []T
+    for item := range itemCh {
+        allItems = append(allItems, item)
+    }
+    if err := <-errCh; err != nil {
+        return nil, err
+    }
+
+    log.Printf("... Total %d items loaded.", len(allItems))
+    return allItems, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "strings"
+)
+
+// Plan is the read-only result of a --dry-run: what tags would be created
+// and which notes would receive which tags, without touching Joplin.
+type Plan struct {
+    TagsToCreate []string         `json:"tags_to_create"`
+    NotesToTag   []PlannedNoteTag `json:"notes_to_tag"`
+}
+
+// PlannedNoteTag is one note/tag pair that would be applied by a real run.
+type PlannedNoteTag struct {
+    NoteID    string `json:"note_id"`
+    NoteTitle string `json:"note_title"`
+    TagName   string `json:"tag_name"`
+}
+
+// buildPlan computes the same decisions steps 3 and 5 would make, without
+// calling the API.
+func buildPlan(notes []Note, requiredTagNames map[string]struct{}, normalizedTagNameToID map[string]string, folderIDToNormalizedTagName map[string]string, ancestorChain map[string][]string, applyAncestors bool) Plan {
+    var plan Plan
+
+    for name := range requiredTagNames {
+        if _, exists := normalizedTagNameToID[strings.ToLower(name)]; !exists {
+            plan.TagsToCreate = append(plan.TagsToCreate, name)
+        }
+    }
+
+    for _, note := range notes {
+        for _, folderID := range tagFolderIDsForNote(note.ParentID, ancestorChain, applyAncestors) {
+            normalizedTagName, ok := folderIDToNormalizedTagName[folderID]
+            if !ok {
+                continue
+            }
+            plan.NotesToTag = append(plan.NotesToTag, PlannedNoteTag{
+                NoteID:    note.ID,
+                NoteTitle: note.Title,
+                TagName:   normalizedTagName,
+            })
+        }
+    }
+
+    return plan
+}
+
+// printPlan renders the plan as JSON (--plan-json) or as a human-readable
+// summary followed by the individual note/tag pairs.
+func printPlan(out io.Writer, plan Plan, asJSON bool) error {
+    if asJSON {
+        enc := json.NewEncoder(out)
+        enc.SetIndent("", "  ")
+        return enc.Encode(plan)
+    }
+
+    fmt.Fprintf(out, "\n=== PLAN ===\n")
+    fmt.Fprintf(out, "Tags to create (%d):\n", len(plan.TagsToCreate))
+    for _, name := range plan.TagsToCreate {
+        fmt.Fprintf(out, "  + %s\n", name)
+    }
+
+    fmt.Fprintf(out, "Notes to tag (%d):\n", len(plan.NotesToTag))
+    for _, nt := range plan.NotesToTag {
+        fmt.Fprintf(out, "  %s (%s) -> %s\n", nt.NoteTitle, nt.NoteID, nt.TagName)
+    }
+
+    return nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/spf13/cobra"
+)
+
+// ReconcileOptions holds the flags specific to the reconcile subcommand.
+type ReconcileOptions struct {
+    Prune       bool
+    OrphanGrace time.Duration
+}
+
+// graceDurationValue is a pflag.Value that accepts everything
+// time.ParseDuration does, plus a bare "<N>d" day suffix (e.g. "7d"),
+// since Go's duration parser has no notion of days.
+type graceDurationValue struct {
+    d *time.Duration
+}
+
+func (v graceDurationValue) String() string {
+    if v.d == nil {
+        return "0s"
+    }
+    return v.d.String()
+}
+
+func (v graceDurationValue) Set(s string) error {
+    if days, ok := strings.CutSuffix(s, "d"); ok {
+        n, err := strconv.Atoi(days)
+        if err != nil {
+            return fmt.Errorf("invalid day count %q: %w", days, err)
+        }
+        *v.d = time.Duration(n) * 24 * time.Hour
+        return nil
+    }
+
+    d, err := time.ParseDuration(s)
+    if err != nil {
+        return err
+    }
+    *v.d = d
+    return nil
+}
+
+func (v graceDurationValue) Type() string {
+    return "duration"
+}
+
+// newReconcileCmd builds the `reconcile` subcommand, which removes
+// notebook.* tags that no longer correspond to any notebook.
+func newReconcileCmd(opts *Options) *cobra.Command {
+    reconcileOpts := &ReconcileOptions{OrphanGrace: 7 * 24 * time.Hour}
+
+    cmd := &cobra.Command{
+        Use:   "reconcile",
+        Short: "Find and optionally prune notebook.* tags that no longer correspond to a notebook",
+        RunE: func(cmd *cobra.Command, args []string) error {
+            cfg, err := resolveConfig(cmd, opts)
+            if err != nil {
+                return err
+            }
+            return runReconcile(cmd.Context(), cfg, opts, reconcileOpts)
+        },
+    }
+
+    cmd.Flags().BoolVar(&reconcileOpts.Prune, "prune", false, "delete orphaned tags and stale note/tag associations instead of just printing a plan")
+    cmd.Flags().Var(graceDurationValue{&reconcileOpts.OrphanGrace}, "orphan-grace", "how long a tag must be orphaned before --prune deletes it (e.g. 7d, 12h)")
+
+    return cmd
+}
+
+// orphanState tracks, per orphaned tag ID, the moment it was first observed
+// to no longer correspond to a notebook. It is persisted between runs so
+// --orphan-grace can be enforced without re-running the whole pipeline.
+type orphanState struct {
+    FirstSeen map[string]time.Time `json:"first_seen"`
+}
+
+// orphanStatePath resolves the state file location, following the
+// XDG Base Directory spec with a $HOME/.local/state fallback.
+func orphanStatePath() (string, error) {
+    dir := os.Getenv("XDG_STATE_HOME")
+    if dir == "" {
+        home, err := os.UserHomeDir()
+        if err != nil {
+            return "", fmt.Errorf("resolving home directory: %w", err)
+        }
+        dir = filepath.Join(home, ".local", "state")
+    }
+    return filepath.Join(dir, "joplin-autotagger", "reconcile-state.json"), nil
+}
+
+func loadOrphanState(path string) (*orphanState, error) {
+    data, err := os.ReadFile(path)
+    if os.IsNotExist(err) {
+        return &orphanState{FirstSeen: make(map[string]time.Time)}, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("reading orphan state: %w", err)
+    }
+
+    var s orphanState
+    if err := json.Unmarshal(data, &s); err != nil {
+        return nil, fmt.Errorf("parsing orphan state: %w", err)
+    }
+    if s.FirstSeen == nil {
+        s.FirstSeen = make(map[string]time.Time)
+    }
+    return &s, nil
+}
+
+func (s *orphanState) save(path string) error {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+        return fmt.Errorf("creating state directory: %w", err)
+    }
+    data, err := json.MarshalIndent(s, "", "  ")
+    if err != nil {
+        return fmt.Errorf("encoding orphan state: %w", err)
+    }
+    return os.WriteFile(path, data, 0o644)
+}
+
+// runReconcile loads the current notebook/tag set, diffs it against all
+// tags carrying TAG_PREFIX, and deletes (or plans the deletion of) any tag
+// that no longer corresponds to a notebook once it has been orphaned for
+// longer than --orphan-grace. It also drops stale notebook.* tags left on
+// notes whose parent notebook has since changed.
+func runReconcile(ctx context.Context, cfg Config, opts *Options, reconcileOpts *ReconcileOptions) error {
+    out := io.Writer(os.Stdout)
+    if opts.Silent {
+        out = io.Discard
+    }
+    l := logger{out: out}
+
+    client := newAPIClient(cfg)
+
+    folders, err := fetchAll[Folder](ctx, client, "/folders?fields=id,title,parent_id")
+    if err != nil {
+        return fmt.Errorf("critical error when loading notebooks: %w", err)
+    }
+
+    folderIDToNormalizedTagName, _, ancestorChain, err := folderTagNames(folders, cfg, opts)
+    if err != nil {
+        return err
+    }
+
+    desiredNormalizedTagNames := make(map[string]struct{}, len(folderIDToNormalizedTagName))
+    for _, normalizedTagName := range folderIDToNormalizedTagName {
+        desiredNormalizedTagNames[normalizedTagName] = struct{}{}
+    }
+
+    existingTags, err := fetchAll[Tag](ctx, client, "/tags?fields=id,title")
+    if err != nil {
+        return fmt.Errorf("critical error while loading tags: %w", err)
+    }
+
+    lowerPrefix := strings.ToLower(cfg.TagPrefix)
+    var orphans []Tag
+    for _, t := range existingTags {
+        normalized := strings.ToLower(t.Title)
+        if !strings.HasPrefix(normalized, lowerPrefix) {
+            continue
+        }
+        if _, desired := desiredNormalizedTagNames[normalized]; !desired {
+            orphans = append(orphans, t)
+        }
+    }
+    l.Printf("Found %d orphaned notebook tags out of %d total tags.\n", len(orphans), len(existingTags))
+
+    statePath, err := orphanStatePath()
+    if err != nil {
+        return err
+    }
+    state, err := loadOrphanState(statePath)
+    if err != nil {
+        return err
+    }
+
+    now := time.Now()
+    stillOrphaned := make(map[string]struct{}, len(orphans))
+    var toDelete []Tag
+    for _, t := range orphans {
+        stillOrphaned[t.ID] = struct{}{}
+
+        firstSeen, known := state.FirstSeen[t.ID]
+        if !known {
+            state.FirstSeen[t.ID] = now
+            l.Printf("... Tag '%s' is newly orphaned; grace period of %s starts now.\n", t.Title, reconcileOpts.OrphanGrace)
+            continue
+        }
+
+        if now.Sub(firstSeen) >= reconcileOpts.OrphanGrace {
+            toDelete = append(toDelete, t)
+        } else {
+            l.Printf("... Tag '%s' has been orphaned for %s, still within the %s grace period.\n", t.Title, now.Sub(firstSeen).Round(time.Second), reconcileOpts.OrphanGrace)
+        }
+    }
+
+    // A tag that is no longer orphaned (e.g. the notebook was recreated) no
+    // longer needs to be tracked.
+    for id := range state.FirstSeen {
+        if _, ok := stillOrphaned[id]; !ok {
+            delete(state.FirstSeen, id)
+        }
+    }
+
+    for _, t := range toDelete {
+        if !reconcileOpts.Prune || opts.DryRun {
+            l.Printf("PLAN: would delete orphaned tag '%s' (past grace period)\n", t.Title)
+            continue
+        }
+
+        l.Printf("... Deleting orphaned tag: %s\n", t.Title)
+        if _, err := client.makeAPIRequest(ctx, "DELETE", fmt.Sprintf("/tags/%s", t.ID), nil); err != nil {
+            log.Printf("Error deleting tag '%s': %v. We continue.", t.Title, err)
+            continue
+        }
+        delete(state.FirstSeen, t.ID)
+    }
+
+    if err := state.save(statePath); err != nil {
+        return fmt.Errorf("saving orphan state: %w", err)
+    }
+
+    return reparentNotes(ctx, client, l, cfg, opts, reconcileOpts, folderIDToNormalizedTagName, ancestorChain)
+}
+
+// reparentNotes drops stale notebook.* tags from notes whose parent_id no
+// longer matches the notebook (or, with --apply-ancestors, none of the
+// ancestor notebooks) that tag was derived from, e.g. because the note was
+// moved to a different notebook after being tagged. Like the orphaned-tag
+// deletions above, this only mutates anything with --prune; a bare
+// `reconcile` (or `reconcile --dry-run`) only reports what it would remove.
+func reparentNotes(ctx context.Context, client *apiClient, l logger, cfg Config, opts *Options, reconcileOpts *ReconcileOptions, folderIDToNormalizedTagName map[string]string, ancestorChain map[string][]string) error {
+    notes, err := fetchAll[Note](ctx, client, "/notes?fields=id,title,parent_id")
+    if err != nil {
+        return fmt.Errorf("critical error while loading notes: %w", err)
+    }
+
+    l.Printf("\n--- Checking %d notes for stale notebook tags ---\n", len(notes))
+
+    runWorkerPool(ctx, cfg.Concurrency, notes, func(ctx context.Context, note Note) {
+        correctFolderIDs := tagFolderIDsForNote(note.ParentID, ancestorChain, opts.ApplyAncestors)
+        correctTagNames := make(map[string]struct{}, len(correctFolderIDs))
+        for _, id := range correctFolderIDs {
+            correctTagNames[folderIDToNormalizedTagName[id]] = struct{}{}
+        }
+
+        noteTags, err := fetchAll[Tag](ctx, client, fmt.Sprintf("/notes/%s/tags?fields=id,title", note.ID))
+        if err != nil {
+            log.Printf("Error loading tags for note '%s': %v. We continue.", note.Title, err)
+            return
+        }
+
+        lowerPrefix := strings.ToLower(cfg.TagPrefix)
+        for _, nt := range noteTags {
+            normalized := strings.ToLower(nt.Title)
+            if !strings.HasPrefix(normalized, lowerPrefix) {
+                continue
+            }
+            if _, ok := correctTagNames[normalized]; ok {
+                continue
+            }
+
+            if !reconcileOpts.Prune || opts.DryRun {
+                l.Printf("PLAN: would remove stale tag '%s' from note '%s'\n", nt.Title, note.ID)
+                continue
+            }
+
+            l.Printf("... Removing stale tag '%s' from re-parented note '%s'\n", nt.Title, note.ID)
+            endpoint := fmt.Sprintf("/tags/%s/notes/%s", nt.ID, note.ID)
+            if _, err := client.makeAPIRequest(ctx, "DELETE", endpoint, nil); err != nil {
+                log.Printf("Error removing stale tag '%s' from note '%s': %v. We continue.", nt.Title, note.ID, err)
+            }
+        }
+    })
+
+    return nil
+}
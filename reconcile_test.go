@@ -0,0 +1,43 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestGraceDurationValueSet(t *testing.T) {
+    tests := []struct {
+        name    string
+        input   string
+        want    time.Duration
+        wantErr bool
+    }{
+        {name: "day suffix", input: "7d", want: 7 * 24 * time.Hour},
+        {name: "zero days", input: "0d", want: 0},
+        {name: "standard Go duration", input: "12h", want: 12 * time.Hour},
+        {name: "standard Go duration with minutes", input: "90m", want: 90 * time.Minute},
+        {name: "invalid day count", input: "xd", wantErr: true},
+        {name: "invalid duration", input: "not-a-duration", wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var d time.Duration
+            v := graceDurationValue{&d}
+
+            err := v.Set(tt.input)
+            if tt.wantErr {
+                if err == nil {
+                    t.Fatalf("Set(%q) = nil error, want one", tt.input)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("Set(%q) returned unexpected error: %v", tt.input, err)
+            }
+            if d != tt.want {
+                t.Errorf("Set(%q) = %v, want %v", tt.input, d, tt.want)
+            }
+        })
+    }
+}
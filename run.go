@@ -0,0 +1,261 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "strings"
+    "sync"
+    "sync/atomic"
+
+    "github.com/cheggaaa/pb/v3"
+)
+
+// logger is the subset of output used by run(); in --silent mode it is
+// routed to io.Discard so no informational text reaches the terminal.
+type logger struct {
+    out io.Writer
+}
+
+func (l logger) Printf(format string, a ...any) {
+    fmt.Fprintf(l.out, format, a...)
+}
+
+func (l logger) Println(a ...any) {
+    fmt.Fprintln(l.out, a...)
+}
+
+// newBar returns a progress bar for total items, or a no-op bar when
+// progress output has been disabled (--silent or --no-progress).
+func newBar(total int, prefix string, opts *Options) *pb.ProgressBar {
+    bar := pb.New(total)
+    bar.Set(pb.Bytes, false)
+    bar.SetTemplateString(fmt.Sprintf(`%s {{counters . }} {{bar . }} {{percent . }}`, prefix))
+    if opts.Silent || opts.NoProgress {
+        bar.SetWriter(io.Discard)
+    }
+    return bar.Start()
+}
+
+// folderTagNames derives, from the current notebook set, the tag name each
+// folder should carry (its hierarchical path, with --overrides applied) and
+// the ancestor chain used by --apply-ancestors. Folders whose title is
+// excluded by cfg's Include/Exclude filter are left out of the returned
+// maps entirely, so they are neither tagged themselves nor have a tag
+// created for them.
+func folderTagNames(folders []Folder, cfg Config, opts *Options) (folderIDToNormalizedTagName map[string]string, requiredTagNames map[string]struct{}, ancestorChain map[string][]string, err error) {
+    filter, err := cfg.titleFilter()
+    if err != nil {
+        return nil, nil, nil, err
+    }
+
+    folderIDToTagName, ancestorChain := buildFolderTagNames(folders, cfg.TagPrefix, opts.hierarchyOptions())
+
+    byID := make(map[string]Folder, len(folders))
+    for _, f := range folders {
+        byID[f.ID] = f
+    }
+
+    folderIDToNormalizedTagName = make(map[string]string, len(folderIDToTagName))
+    requiredTagNames = make(map[string]struct{}, len(folderIDToTagName))
+    for id, computedTagName := range folderIDToTagName {
+        if f, ok := byID[id]; ok && !filter(f.Title) {
+            continue
+        }
+        prefixedTagName := cfg.tagNameForFolder(id, computedTagName)
+        folderIDToNormalizedTagName[id] = strings.ToLower(prefixedTagName)
+        requiredTagNames[prefixedTagName] = struct{}{}
+    }
+    return folderIDToNormalizedTagName, requiredTagNames, ancestorChain, nil
+}
+
+// run executes the five-step tagging pipeline, reporting progress via bars
+// (unless suppressed) and aborting cleanly when ctx is cancelled. With
+// --dry-run it performs only the read-only steps (1, 2 and 4) and prints a
+// plan instead of creating tags or tagging notes.
+func run(ctx context.Context, cfg Config, opts *Options) error {
+    log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+
+    out := io.Writer(os.Stdout)
+    if opts.Silent {
+        out = io.Discard
+        log.SetOutput(io.Discard)
+    }
+    l := logger{out: out}
+
+    l.Println("=== START: Automatically Tagging Joplin Notes ===")
+    l.Printf("The tag prefix to use: %s\n", cfg.TagPrefix)
+    if opts.DryRun {
+        l.Println("(dry-run: steps 1, 2 and 4 only; no tags will be created and no notes will be tagged)")
+    }
+
+    client := newAPIClient(cfg)
+
+    // 1. GETTING NOTEBOOKS
+    l.Println("\n--- 1. Downloading all notebooks and collecting unique titles ---")
+    folders, err := fetchAll[Folder](ctx, client, "/folders?fields=id,title,parent_id")
+    if err != nil {
+        return fmt.Errorf("critical error when loading notebooks: %w", err)
+    }
+
+    folderIDToNormalizedTagName, requiredTagNames, ancestorChain, err := folderTagNames(folders, cfg, opts)
+    if err != nil {
+        return err
+    }
+    l.Printf("Found %d notebooks. You need to create %d unique tags (with a prefix).\n", len(folders), len(requiredTagNames))
+
+    // 2. OBTAINING TAGS
+    l.Println("\n--- 2. Loading existing tags ---")
+    existingTags, err := fetchAll[Tag](ctx, client, "/tags?fields=id,title")
+    if err != nil {
+        return fmt.Errorf("critical error while loading tags: %w", err)
+    }
+
+    // Map NORMALIZED Tag name -> ID (for quick existence check)
+    normalizedTagNameToID := make(map[string]string)
+    for _, t := range existingTags {
+        normalizedTagNameToID[strings.ToLower(t.Title)] = t.ID
+    }
+    l.Printf("Found %d existing tags.\n", len(existingTags))
+
+    if opts.DryRun {
+        // The plan needs the complete note set up front, so this is the one
+        // place outside step 1 that still pulls the whole collection.
+        l.Println("\n--- 4. Download all notes ---")
+        notes, err := fetchAll[Note](ctx, client, "/notes?fields=id,title,parent_id")
+        if err != nil {
+            return fmt.Errorf("critical error while loading notes: %w", err)
+        }
+        l.Printf("Loaded %d notes for processing.\n", len(notes))
+
+        plan := buildPlan(notes, requiredTagNames, normalizedTagNameToID, folderIDToNormalizedTagName, ancestorChain, opts.ApplyAncestors)
+        return printPlan(out, plan, opts.PlanJSON)
+    }
+
+    // 3. CREATING MISSING TAGS
+    l.Println("\n--- 3. Creating tags corresponding to notebook names (with a prefix) ---")
+    var tagsCreated int64
+    var tagsMu sync.Mutex
+    bar3 := newBar(len(requiredTagNames), "Creating tags", opts)
+
+    tagNames := make([]string, 0, len(requiredTagNames))
+    for name := range requiredTagNames {
+        tagNames = append(tagNames, name)
+    }
+
+    runWorkerPool(ctx, cfg.Concurrency, tagNames, func(ctx context.Context, originalName string) {
+        defer bar3.Increment()
+
+        normalizedName := strings.ToLower(originalName)
+
+        tagsMu.Lock()
+        _, exists := normalizedTagNameToID[normalizedName]
+        tagsMu.Unlock()
+        if exists {
+            // If the tag already exists, we just skip it because its ID is already in normalizedTagNameToID
+            return
+        }
+
+        l.Printf("... Create a tag: %s\n", originalName)
+
+        newTagData := map[string]string{"title": originalName}
+        body, _ := json.Marshal(newTagData)
+
+        respBody, err := client.makeAPIRequest(ctx, "POST", "/tags", bytes.NewBuffer(body))
+        if err != nil {
+            // The log here will show that the tag already exists (if an error from the API)
+            // Now, if the tag exists, we know it, but we don't know its ID.
+            // If it wasn't found in #2, but caused an "already exists" error here,
+            // this means it was created by another process, or there was an earlier error in the logic.
+            // In order not to fail, we skip this tag, but we do not add the ID to newlyCreatedTagsID.
+            log.Printf("ERROR CREATING TAG '%s': %v. The tag will be skipped in the next step.", originalName, err)
+            return
+        }
+
+        var newTag Tag
+        if err := json.Unmarshal(respBody, &newTag); err != nil {
+            log.Printf("Error parsing new tag: %v. We continue.", err)
+            return
+        }
+
+        // Add the ID of the newly created tag using its normalized name
+        tagsMu.Lock()
+        normalizedTagNameToID[normalizedName] = newTag.ID
+        tagsMu.Unlock()
+        atomic.AddInt64(&tagsCreated, 1)
+    })
+    bar3.Finish()
+
+    l.Printf("Finished creating tags. Created by: %d.\n", tagsCreated)
+
+    // 4/5. STREAMING NOTES AND TAGGING THEM AS THEY ARRIVE
+    // The tags field is no longer requested to avoid SQLITE_ERROR
+    l.Println("\n--- 4/5. Downloading notes and applying tags as they arrive ---")
+    var tagsApplied int64
+    var notesProcessed int64
+    bar5 := newBar(0, "Tagging notes", opts)
+
+    noteCh, noteErrCh := streamAll[Note](ctx, client, "/notes?fields=id,title,parent_id")
+
+    runWorkerPoolChan(ctx, cfg.Concurrency, noteCh, func(ctx context.Context, note Note) {
+        defer atomic.AddInt64(&notesProcessed, 1)
+        defer bar5.Increment()
+
+        // 1. Find which notebook(s) this note should be tagged with: just its
+        // direct notebook, or the whole ancestor chain with --apply-ancestors.
+        folderIDs := tagFolderIDsForNote(note.ParentID, ancestorChain, opts.ApplyAncestors)
+
+        for _, folderID := range folderIDs {
+            normalizedTagName, ok := folderIDToNormalizedTagName[folderID]
+            if !ok {
+                // Excluded by --include/--exclude, or in the root directory.
+                continue
+            }
+
+            // 2. Find the ID of the tag using the normalized name
+            tagsMu.Lock()
+            requiredTagID, exists := normalizedTagNameToID[normalizedTagName]
+            tagsMu.Unlock()
+            if !exists {
+                // !!! FIX: Tag should have been found or created in 2/3. If not, this is a critical error in logic.
+                log.Printf("Error: Could not find tag id for normalized name: %s. We skip the note: %s.", normalizedTagName, note.Title)
+                continue
+            }
+
+            // 3. Apply the tag.
+            l.Printf("... Tagging note: '%s' with ID tag '%s'\n", note.ID, requiredTagID)
+
+            // Endpoint for binding a tag to a note: POST /tags/:tagId/notes
+            taggingEndpoint := fmt.Sprintf("/tags/%s/notes", requiredTagID)
+
+            // The request body contains only the ID of the note
+            tagNoteData := map[string]string{"id": note.ID}
+            body, _ := json.Marshal(tagNoteData)
+
+            _, err := client.makeAPIRequest(ctx, "POST", taggingEndpoint, bytes.NewBuffer(body))
+            if err != nil {
+                // If tagging failed (for example, a network error), log in and continue.
+                log.Printf("Error tagging note '%s': %v. We continue.", note.Title, err)
+                continue
+            }
+
+            atomic.AddInt64(&tagsApplied, 1)
+        }
+    })
+    bar5.Finish()
+
+    if err := <-noteErrCh; err != nil {
+        return fmt.Errorf("critical error while loading notes: %w", err)
+    }
+
+    l.Printf("\n=== COMPLETED ===\n")
+    l.Printf("New tags have been created (with a prefix): %d\n", tagsCreated)
+    l.Printf("Notes processed: %d\n", notesProcessed)
+    l.Printf("Tags successfully applied:%d\n", tagsApplied)
+    l.Println("The script completed successfully. Check out Joplin.")
+    return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+    "context"
+    "sync"
+)
+
+// runWorkerPool fans items out across concurrency goroutines, invoking fn
+// for each one, and blocks until every item has been processed or ctx is
+// cancelled. fn is responsible for handling and logging its own errors;
+// the pool itself only drives concurrency.
+func runWorkerPool[T any](ctx context.Context, concurrency int, items []T, fn func(context.Context, T)) {
+    if concurrency < 1 {
+        concurrency = 1
+    }
+    if concurrency > len(items) {
+        concurrency = len(items)
+    }
+    if concurrency == 0 {
+        return
+    }
+
+    itemCh := make(chan T)
+    go func() {
+        defer close(itemCh)
+    feed:
+        for _, item := range items {
+            select {
+            case itemCh <- item:
+            case <-ctx.Done():
+                break feed
+            }
+        }
+    }()
+
+    runWorkerPoolChan(ctx, concurrency, itemCh, fn)
+}
+
+// runWorkerPoolChan is the channel-driven counterpart of runWorkerPool, for
+// callers that produce items incrementally (e.g. streamAll) rather than
+// holding them all in a slice up front. It fans items out across
+// concurrency goroutines and blocks until the channel is drained (or ctx is
+// cancelled), at which point it returns without waiting on itemCh to close.
+func runWorkerPoolChan[T any](ctx context.Context, concurrency int, itemCh <-chan T, fn func(context.Context, T)) {
+    if concurrency < 1 {
+        concurrency = 1
+    }
+
+    var wg sync.WaitGroup
+    for i := 0; i < concurrency; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for {
+                select {
+                case item, ok := <-itemCh:
+                    if !ok {
+                        return
+                    }
+                    fn(ctx, item)
+                case <-ctx.Done():
+                    return
+                }
+            }
+        }()
+    }
+    wg.Wait()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+    "context"
+    "sync/atomic"
+    "testing"
+    "time"
+)
+
+// BenchmarkRunWorkerPool demonstrates that raising concurrency shortens the
+// wall-clock time for a batch of latency-bound calls, mirroring the
+// POST /tags and POST /tags/:id/notes calls made in steps 3 and 5.
+func BenchmarkRunWorkerPool(b *testing.B) {
+    items := make([]int, 200)
+    simulateCall := func(context.Context, int) {
+        time.Sleep(time.Millisecond)
+    }
+
+    for _, concurrency := range []int{1, 8, 32} {
+        b.Run(concurrencyLabel(concurrency), func(b *testing.B) {
+            for i := 0; i < b.N; i++ {
+                runWorkerPool(context.Background(), concurrency, items, simulateCall)
+            }
+        })
+    }
+}
+
+// TestRunWorkerPoolZeroConcurrency guards against a silent no-op: a
+// concurrency of 0 (reachable via --concurrency 0, JOPLIN_CONCURRENCY=0, or
+// a YAML `concurrency: 0`) must still process every item, not skip them.
+func TestRunWorkerPoolZeroConcurrency(t *testing.T) {
+    items := []int{1, 2, 3, 4, 5}
+    var processed int64
+
+    runWorkerPool(context.Background(), 0, items, func(context.Context, int) {
+        atomic.AddInt64(&processed, 1)
+    })
+
+    if int(processed) != len(items) {
+        t.Fatalf("processed %d of %d items with concurrency=0", processed, len(items))
+    }
+}
+
+func concurrencyLabel(n int) string {
+    switch n {
+    case 1:
+        return "concurrency=1"
+    case 8:
+        return "concurrency=8"
+    default:
+        return "concurrency=32"
+    }
+}